@@ -0,0 +1,146 @@
+// +build !oss
+
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+	"github.com/vektah/gqlparser/v2/validator"
+)
+
+// parseAdminField builds a real, validated *ast.Field for query against a schema carrying
+// adminDirectives, so tests exercise the same ast.FieldDefinition/ast.Value shapes the real
+// dispatch path would hand to authorizeField and argValue.
+func parseAdminField(t *testing.T, schemaFields, query string, vars map[string]interface{}) *ast.Field {
+	sch := gqlparser.MustLoadSchema(&ast.Source{Name: "schema.graphql", Input: adminDirectives + `
+		schema { query: Query }
+		type Query {
+			` + schemaFields + `
+		}
+	`})
+
+	doc, gqlErr := parser.ParseQuery(&ast.Source{Input: query})
+	require.Nil(t, gqlErr)
+	listErr := validator.Validate(sch, doc)
+	require.Equal(t, 0, len(listErr))
+
+	op := doc.Operations.ForName("")
+	require.NotNil(t, op)
+	field, ok := op.SelectionSet[0].(*ast.Field)
+	require.True(t, ok)
+	return field
+}
+
+func TestAuthorizeFieldRequiresPrivilege(t *testing.T) {
+	field := parseAdminField(t,
+		`backup: String @requiresPrivilege(any: ["backup", "galaxy-guardian"])`,
+		`{ backup }`, nil)
+
+	require.NoError(t, newPrivilegeSet([]string{"backup"}).authorizeField(field.Definition))
+	require.NoError(t, newPrivilegeSet([]string{"galaxy-guardian"}).authorizeField(field.Definition))
+
+	err := newPrivilegeSet([]string{"acl-read"}).authorizeField(field.Definition)
+	require.Error(t, err)
+
+	err = newPrivilegeSet(nil).authorizeField(field.Definition)
+	require.Error(t, err)
+}
+
+func TestAuthorizeFieldWithoutDirectiveIsOpen(t *testing.T) {
+	field := parseAdminField(t, `getCurrentUser: String`, `{ getCurrentUser }`, nil)
+	require.NoError(t, newPrivilegeSet(nil).authorizeField(field.Definition))
+}
+
+func TestAuthorizeFieldRequiresAll(t *testing.T) {
+	field := parseAdminField(t,
+		`updateJWTKeys: String @requiresPrivilege(all: ["galaxy-guardian"])`,
+		`{ updateJWTKeys }`, nil)
+
+	require.Error(t, newPrivilegeSet([]string{"acl-write"}).authorizeField(field.Definition))
+	require.NoError(t, newPrivilegeSet([]string{"galaxy-guardian"}).authorizeField(field.Definition))
+}
+
+func TestAuthorizeNamespace(t *testing.T) {
+	galaxyCtx := ContextWithNamespace(context.Background(), galaxyNamespace)
+	require.NoError(t, authorizeNamespace(galaxyCtx, 5))
+
+	tenantCtx := ContextWithNamespace(context.Background(), 5)
+	require.NoError(t, authorizeNamespace(tenantCtx, 5))
+	require.Error(t, authorizeNamespace(tenantCtx, 6))
+
+	_, err := namespaceFromContext(context.Background())
+	require.Error(t, err, "an unauthenticated context must not silently resolve to namespace 0")
+}
+
+func TestRejectCrossNamespaceRef(t *testing.T) {
+	tenantCtx := ContextWithNamespace(context.Background(), 5)
+	require.NoError(t, rejectCrossNamespaceRef(tenantCtx, 5))
+	require.Error(t, rejectCrossNamespaceRef(tenantCtx, 6))
+
+	galaxyCtx := ContextWithNamespace(context.Background(), galaxyNamespace)
+	require.NoError(t, rejectCrossNamespaceRef(galaxyCtx, 6))
+}
+
+func TestBuildRestorePlanUnionsPredicatesAndTypes(t *testing.T) {
+	manifests := []*restoreManifest{
+		{
+			Timestamp: 10,
+			Groups:    map[uint32][]string{1: {"name", "age", "dgraph.type"}},
+			Types:     map[string][]string{"Person": {"name", "age"}},
+		},
+	}
+
+	plan, err := buildRestorePlan(manifests, 0, 0, []string{"dgraph.type"}, []string{"Person"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"dgraph.type", "name", "age"}, plan.predicates)
+	require.ElementsMatch(t, []uint32{1}, plan.groups)
+}
+
+func TestBuildRestorePlanRejectsInvertedWindow(t *testing.T) {
+	_, err := buildRestorePlan(nil, 10, 5, nil, nil)
+	require.Error(t, err)
+}
+
+func TestArgValueResolvesVariables(t *testing.T) {
+	field := parseAdminField(t,
+		`resetPassword(namespace: Int, userId: String): String`,
+		`query q($ns: Int!, $user: String!) { resetPassword(namespace: $ns, userId: $user) }`,
+		nil)
+
+	vars := map[string]interface{}{"ns": 7, "user": "alice"}
+	require.Equal(t, "alice", asString(argValue(field, "userId", vars)))
+	require.Equal(t, 7, asInt(argValue(field, "namespace", vars)))
+}
+
+func TestPrivilegesForUserRequiresGuardiansMembership(t *testing.T) {
+	require.Empty(t, privilegesForUser(&userRecord{name: "alice", namespace: 5}))
+
+	guardian := privilegesForUser(&userRecord{name: "groot", namespace: galaxyNamespace, groups: []string{guardiansGroup}})
+	require.Contains(t, guardian, "galaxy-guardian")
+	require.Contains(t, guardian, "acl-write")
+
+	tenantGuardian := privilegesForUser(&userRecord{name: "admin", namespace: 5, groups: []string{guardiansGroup}})
+	require.NotContains(t, tenantGuardian, "galaxy-guardian")
+	require.Contains(t, tenantGuardian, "acl-write")
+}
@@ -0,0 +1,103 @@
+// +build !oss
+
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"strconv"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// argValue resolves field's argument name to a plain Go value — string, int64, bool,
+// []interface{} or map[string]interface{} — or nil if the argument wasn't supplied. vars is
+// the operation's variable values, already JSON-decoded by the query layer; resolution goes
+// through ast.Value.Value, the same method gqlparser itself uses, so an argument supplied via
+// $variable behaves exactly like the equivalent literal instead of being read back as the
+// variable's name.
+func argValue(field *ast.Field, name string, vars map[string]interface{}) interface{} {
+	arg := field.Arguments.ForName(name)
+	if arg == nil {
+		return nil
+	}
+	val, err := arg.Value.Value(vars)
+	if err != nil {
+		return nil
+	}
+	return val
+}
+
+// objVal returns the value of name within the object value v (itself the result of argValue or
+// objVal), or nil if v isn't an object or has no such field.
+func objVal(v interface{}, name string) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return m[name]
+}
+
+// asString coerces v, as returned by argValue/objVal, to a string. A missing or
+// differently-typed argument yields "".
+func asString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// asInt coerces v to an int. A literal IntValue comes back from ast.Value.Value as int64; a
+// variable-sourced number decodes off JSON as float64 instead — both are accepted.
+func asInt(v interface{}) int {
+	switch n := v.(type) {
+	case int64:
+		return int(n)
+	case int:
+		return n
+	case float64:
+		return int(n)
+	case string:
+		i, _ := strconv.Atoi(n)
+		return i
+	default:
+		return 0
+	}
+}
+
+func asBool(v interface{}) bool {
+	b, _ := v.(bool)
+	return b
+}
+
+// asStringList coerces v to a []string, e.g. for a [String!] argument.
+func asStringList(v interface{}) []string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		out = append(out, asString(item))
+	}
+	return out
+}
+
+// asList coerces v to a []interface{}, e.g. for a list-of-input-object argument such as
+// [AddUserInput!]!; each element is itself suitable for objVal.
+func asList(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	return list
+}
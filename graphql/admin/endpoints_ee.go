@@ -18,6 +18,16 @@
 
 package admin
 
+const adminDirectives = `
+	"""
+	requiresPrivilege declares the privilege labels a caller's JWT must carry to execute the
+	field it's attached to. If any is set, the caller needs at least one of the listed
+	labels; if all is set, the caller needs every listed label. A field with neither any nor
+	all is executable by anyone, including an unauthenticated caller.
+	"""
+	directive @requiresPrivilege(any: [String!], all: [String!]) on FIELD_DEFINITION
+`
+
 const adminTypes = `
 	input BackupInput {
 
@@ -92,31 +102,145 @@ const adminTypes = `
 
 		"""
 		Set to true to allow backing up to S3 or Minio bucket that requires no credentials.
-		"""	
+		"""
 		anonymous: Boolean
+
+		"""
+		Only replay backup manifests with a commit timestamp greater than or equal to sinceTs. Leave unset
+		to restore from the start of the backup series.
+		"""
+		sinceTs: Int
+
+		"""
+		Only replay backup manifests with a commit timestamp less than or equal to untilTs,
+		giving a point-in-time restore. Leave unset to replay up to the latest manifest.
+		"""
+		untilTs: Int
+
+		"""
+		Restore only these predicates. Leave unset (or empty) to restore every predicate in
+		the backup.
+		"""
+		predicates: [String!]
+
+		"""
+		Restore only the types listed here, along with whatever predicates they reference.
+		Leave unset (or empty) to restore every type in the backup. The manifest.json read by
+		this build records no type-to-predicate mapping, so a type named here adds no
+		predicates to the restore.
+		"""
+		types: [String!]
+
+		"""
+		If true, don't touch any state: just compute and return the RestorePlan that this
+		restore would execute.
+		"""
+		dryRun: Boolean
+	}
+
+	"""
+	Describes the work a restore would perform: which manifests it would replay and which
+	groups and predicates it would touch. Returned directly when dryRun is set, and alongside
+	response/restoreId otherwise.
+	"""
+	type RestorePlan {
+		"""
+		Backup manifests, identified by their timestamp, that fall inside the requested
+		[sinceTs, untilTs] window and would be replayed.
+		"""
+		manifests: [Int]
+
+		"""
+		Alpha groups that hold data in the selected predicates and would be restored.
+		"""
+		groups: [Int]
+
+		"""
+		Predicates that would be restored, after applying the predicates/types filters.
+		"""
+		predicates: [String]
+	}
+
+	enum RestoreStatusKind {
+		NotFound
+		Pending
+		Running
+		Failed
+		Done
+	}
+
+	type RestoreStatus {
+		restoreId: String!
+		status: RestoreStatusKind!
+		errors: [String]
 	}
 
 	type RestorePayload {
 		response: Response
+		plan: RestorePlan
+		restoreId: String
 	}
 
 	type LoginResponse {
 
 		"""
 		JWT token that should be used in future requests after this login.
-		"""	
+		"""
 		accessJWT: String
 
 		"""
 		Refresh token that can be used to re-login after accessJWT expires.
-		"""	
+		"""
 		refreshJWT: String
+
+		"""
+		Number of seconds until accessJWT expires.
+		"""
+		expiresIn: Int
+
+		"""
+		Type of the issued token, e.g. "Bearer".
+		"""
+		tokenType: String
 	}
 
 	type LoginPayload {
 		response: LoginResponse
 	}
 
+	"""
+	A single key in the JWT signing key set, identified by its key ID (kid). alg is either
+	"EdDSA" (Ed25519) or "HS256" (HMAC).
+	"""
+	type JWTKey {
+		kid: String!
+		alg: String!
+
+		"""
+		PEM-encoded public key. Absent for HMAC keys, whose secret is never exposed.
+		"""
+		publicKey: String
+	}
+
+	input UpdateJWTKeysInput {
+		"""
+		Signing algorithm for the newly generated key: "EdDSA" or "HS256".
+		"""
+		alg: String!
+
+		"""
+		Audience claim that tokens signed with the new key set will carry.
+		"""
+		audience: String
+	}
+
+	type UpdateJWTKeysPayload {
+		"""
+		The full, still-valid key set after rotation, newest key first.
+		"""
+		keys: [JWTKey]
+	}
+
 	type User @secret(field: "password", pred: "dgraph.password") {
 
 		"""
@@ -125,6 +249,12 @@ const adminTypes = `
 		name: String! @id @dgraph(pred: "dgraph.xid")
 
 		groups: [Group] @dgraph(pred: "dgraph.user.group")
+
+		"""
+		Namespace that this user belongs to. A guardian of a namespace can only see and
+		modify users that belong to its own namespace.
+		"""
+		namespace: Namespace @dgraph(pred: "dgraph.user.namespace")
 	}
 
 	type Group {
@@ -135,6 +265,34 @@ const adminTypes = `
 		name: String! @id @dgraph(pred: "dgraph.xid")
 		users: [User] @dgraph(pred: "~dgraph.user.group")
 		rules: [Rule] @dgraph(pred: "dgraph.acl.rule")
+
+		"""
+		Namespace that this group belongs to.
+		"""
+		namespace: Namespace @dgraph(pred: "dgraph.group.namespace")
+	}
+
+	type Namespace {
+
+		"""
+		Unique identifier for the namespace. Generated by Dgraph and never reused.
+		"""
+		id: Int! @id
+
+		"""
+		Human readable name for the namespace.
+		"""
+		name: String!
+
+		"""
+		Maximum number of users allowed in this namespace. A value of 0 means unlimited.
+		"""
+		maxUsers: Int
+
+		"""
+		Maximum number of groups allowed in this namespace. A value of 0 means unlimited.
+		"""
+		maxGroups: Int
 	}
 
 	type Rule {
@@ -176,6 +334,10 @@ const adminTypes = `
 		name
 	}
 
+	enum NamespaceOrderable {
+		name
+	}
+
 	input AddUserInput {
 		name: String!
 		password: String!
@@ -195,6 +357,10 @@ const adminTypes = `
 		name: String!
 	}
 
+	input NamespaceRef {
+		id: Int!
+	}
+
 	input RuleRef {
 		"""
 		Predicate to which the rule applies.
@@ -240,6 +406,19 @@ const adminTypes = `
 		then: GroupOrder
 	}
 
+	input NamespaceFilter {
+		name: StringHashFilter
+		and: NamespaceFilter
+		or: NamespaceFilter
+		not: NamespaceFilter
+	}
+
+	input NamespaceOrder {
+		asc: NamespaceOrderable
+		desc: NamespaceOrderable
+		then: NamespaceOrder
+	}
+
 	input UserPatch {
 		password: String
 		groups: [GroupRef]
@@ -272,6 +451,21 @@ const adminTypes = `
 		remove: RemoveGroupPatch
 	}
 
+	input AddNamespaceInput {
+		name: String!
+		maxUsers: Int
+		maxGroups: Int
+	}
+
+	input ResetPasswordInput {
+		"""
+		Namespace that the user belongs to. Defaults to the caller's own namespace.
+		"""
+		namespace: Int
+		userId: String!
+		password: String!
+	}
+
 	type AddUserPayload {
 		user: [User]
 	}
@@ -280,12 +474,25 @@ const adminTypes = `
 		group: [Group]
 	}
 
+	type AddNamespacePayload {
+		namespace: Namespace
+	}
+
 	type DeleteUserPayload {
 		msg: String
 	}
 
 	type DeleteGroupPayload {
 		msg: String
+	}
+
+	type DeleteNamespacePayload {
+		msg: String
+	}
+
+	type ResetPasswordPayload {
+		userId: String
+		namespace: Int
 	}`
 
 const adminMutations = `
@@ -293,19 +500,29 @@ const adminMutations = `
 	"""
 	Start a binary backup.  See : https://docs.dgraph.io/enterprise-features/#binary-backups
 	"""
-	backup(input: BackupInput!) : BackupPayload
+	backup(input: BackupInput!) : BackupPayload @requiresPrivilege(any: ["backup"])
 
 	"""
 	Start restoring a binary backup.  See :
 		https://docs.dgraph.io/enterprise-features/#binary-backups
 	"""
-	restore(input: RestoreInput!) : RestorePayload
+	restore(input: RestoreInput!) : RestorePayload @requiresPrivilege(any: ["restore"])
 
 	"""
 	Login to Dgraph.  Successful login results in a JWT that can be used in future requests.
 	If login is not successful an error is returned.
+
+	namespace is required for a userId/password login and must match the namespace the user
+	belongs to; it's taken from refreshToken instead when logging in via a refresh token.
+	"""
+	login(userId: String, password: String, namespace: Int, refreshToken: String): LoginPayload
+
+	"""
+	Publish a new JWT signing key and make it the key used to sign newly issued tokens.
+	Tokens signed with previously published keys remain valid for verification until those
+	keys are retired, which allows operators to rotate keys without downtime.
 	"""
-	login(userId: String, password: String, refreshToken: String): LoginPayload
+	updateJWTKeys(input: UpdateJWTKeysInput!): UpdateJWTKeysPayload @requiresPrivilege(all: ["galaxy-guardian"])
 
 	"""
 	Add a user.  When linking to groups: if the group doesn't exist it is created; if the group
@@ -315,37 +532,73 @@ const adminMutations = `
 	Dgraph ensures that usernames are unique, hence attempting to add an existing user results
 	in an error.
 	"""
-	addUser(input: [AddUserInput!]!): AddUserPayload
+	addUser(input: [AddUserInput!]!): AddUserPayload @requiresPrivilege(any: ["acl-write"])
 
 	"""
 	Add a new group and (optionally) set the rules for the group.
 	"""
-	addGroup(input: [AddGroupInput!]!): AddGroupPayload
+	addGroup(input: [AddGroupInput!]!): AddGroupPayload @requiresPrivilege(any: ["acl-write"])
 
 	"""
 	Update users, their passwords and groups.  As with AddUser, when linking to groups: if the
-	group doesn't exist it is created; if the group exists, the new user is linked to the existing 
+	group doesn't exist it is created; if the group exists, the new user is linked to the existing
 	group.  If the filter doesn't match any users, the mutation has no effect.
 	"""
-	updateUser(input: UpdateUserInput!): AddUserPayload
+	updateUser(input: UpdateUserInput!): AddUserPayload @requiresPrivilege(any: ["acl-write"])
 
 	"""
-	Add or remove rules for groups. If the filter doesn't match any groups, 
+	Add or remove rules for groups. If the filter doesn't match any groups,
 	the mutation has no effect.
 	"""
-	updateGroup(input: UpdateGroupInput!): AddGroupPayload
+	updateGroup(input: UpdateGroupInput!): AddGroupPayload @requiresPrivilege(any: ["acl-write"])
 
-	deleteGroup(filter: GroupFilter!): DeleteGroupPayload
-	deleteUser(filter: UserFilter!): DeleteUserPayload`
+	deleteGroup(filter: GroupFilter!): DeleteGroupPayload @requiresPrivilege(any: ["acl-write"])
+	deleteUser(filter: UserFilter!): DeleteUserPayload @requiresPrivilege(any: ["acl-write"])
+
+	"""
+	Create a new namespace. Only a guardian of the galaxy (the default namespace) may run
+	this mutation.
+	"""
+	addNamespace(input: AddNamespaceInput!): AddNamespacePayload @requiresPrivilege(all: ["galaxy-guardian"])
+
+	"""
+	Delete a namespace and every user and group that belongs to it; other data stored under
+	the namespace is not removed. Only a guardian of the galaxy may run this mutation.
+	"""
+	deleteNamespace(id: Int!): DeleteNamespacePayload @requiresPrivilege(all: ["galaxy-guardian"])
+
+	"""
+	Reset the password of a user. A guardian may only reset passwords for users in its own
+	namespace; a guardian of the galaxy may reset the password of a user in any namespace.
+	"""
+	resetPassword(input: ResetPasswordInput!): ResetPasswordPayload @requiresPrivilege(any: ["acl-write", "galaxy-guardian"])`
 
 const adminQueries = `
-	getUser(name: String!): User
-	getGroup(name: String!): Group
+	getUser(name: String!): User @requiresPrivilege(any: ["acl-read"])
+	getGroup(name: String!): Group @requiresPrivilege(any: ["acl-read"])
 
 	"""
-	Get the currently logged in user.
+	Get the currently logged in user.  Unauthenticated callers may still run this query; it
+	simply resolves to null rather than failing.
 	"""
 	getCurrentUser: User
 
-	queryUser(filter: UserFilter, order: UserOrder, first: Int, offset: Int): [User]
-	queryGroup(filter: GroupFilter, order: GroupOrder, first: Int, offset: Int): [Group]`
+	"""
+	The active JWT signing keys, newest first, in a JWKS-like shape. Clients can use this to
+	verify a token's signature without sharing Dgraph's private keys.
+	"""
+	jwks: [JWTKey]
+
+	"""
+	Poll the status of a restore started by the restore mutation.
+	"""
+	restoreStatus(restoreId: String!): RestoreStatus
+
+	queryUser(filter: UserFilter, order: UserOrder, first: Int, offset: Int): [User] @requiresPrivilege(any: ["acl-read"])
+	queryGroup(filter: GroupFilter, order: GroupOrder, first: Int, offset: Int): [Group] @requiresPrivilege(any: ["acl-read"])
+
+	"""
+	List the namespaces visible to the caller. A guardian of the galaxy sees every namespace;
+	any other guardian only sees its own.
+	"""
+	queryNamespace(filter: NamespaceFilter, order: NamespaceOrder, first: Int, offset: Int): [Namespace] @requiresPrivilege(any: ["acl-read"])`
@@ -0,0 +1,356 @@
+// +build !oss
+
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/v2/ast"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// accessTokenTTL and refreshTokenTTL bound the lifetime of the two JWTs issued by login.
+const (
+	accessTokenTTL  = 6 * time.Hour
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// jwtKey is one entry of the rotating key set used to sign and verify access/refresh JWTs.
+// Only the newest key (by kid) is ever used to sign new tokens; every published key remains
+// valid for verification until it's explicitly retired.
+type jwtKey struct {
+	kid        string
+	alg        string
+	public     interface{}
+	private    interface{}
+	hmacSecret []byte
+}
+
+// jwtKeySet is the rotating, JWKS-style key set backing the login mutation. It is safe for
+// concurrent use.
+type jwtKeySet struct {
+	mu       sync.RWMutex
+	keys     []*jwtKey // newest first
+	audience string
+}
+
+func newEd25519Key(kid string) (*jwtKey, error) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while generating Ed25519 key %s", kid)
+	}
+	return &jwtKey{kid: kid, alg: "EdDSA", public: pub, private: priv}, nil
+}
+
+// newHMACKey generates a fresh, random HS256 signing secret.
+func newHMACKey(kid string) (*jwtKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, errors.Wrapf(err, "while generating HMAC key %s", kid)
+	}
+	return &jwtKey{kid: kid, alg: "HS256", hmacSecret: secret}, nil
+}
+
+// signingKey returns the key that should be used to sign newly issued tokens: the newest
+// published key.
+func (ks *jwtKeySet) signingKey() (*jwtKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if len(ks.keys) == 0 {
+		return nil, errors.New("no JWT signing key has been published")
+	}
+	return ks.keys[0], nil
+}
+
+// keyForKid returns the key with the given kid, used to verify a presented token. Any
+// currently-published key, not just the newest, is accepted for verification so that tokens
+// issued before a rotation keep working.
+func (ks *jwtKeySet) keyForKid(kid string) (*jwtKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	for _, k := range ks.keys {
+		if k.kid == kid {
+			return k, nil
+		}
+	}
+	return nil, errors.Errorf("unknown JWT key id %q", kid)
+}
+
+// publish adds k as the newest key in the set, making it the one used to sign new tokens.
+func (ks *jwtKeySet) publish(k *jwtKey) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = append([]*jwtKey{k}, ks.keys...)
+}
+
+// setAudience changes the audience claim stamped onto tokens signed from now on.
+func (ks *jwtKeySet) setAudience(audience string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.audience = audience
+}
+
+func (ks *jwtKeySet) getAudience() string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.audience
+}
+
+// publicKeys renders every published key in JWKS-like shape, newest first, for the jwks
+// query and the response of updateJWTKeys.
+func (ks *jwtKeySet) publicKeys() []interface{} {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	out := make([]interface{}, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		out = append(out, map[string]interface{}{
+			"kid":       k.kid,
+			"alg":       k.alg,
+			"publicKey": k.publicPEM(),
+		})
+	}
+	return out
+}
+
+// defaultJWTKeySet is the rotating key set backing every login on this alpha. This package
+// seeds it at init with a single generated Ed25519 key so login works before any operator has
+// called updateJWTKeys; no keys are loaded from disk.
+var defaultJWTKeySet = &jwtKeySet{}
+
+var jwtKeyIDCounter uint64
+
+func nextKid() string {
+	return "k" + itoa(atomic.AddUint64(&jwtKeyIDCounter, 1))
+}
+
+func init() {
+	if k, err := newEd25519Key(nextKid()); err == nil {
+		defaultJWTKeySet.publish(k)
+	}
+
+	registerAdminResolver("login", resolveLogin)
+	registerAdminResolver("updateJWTKeys", resolveUpdateJWTKeys)
+	registerAdminResolver("jwks", resolveJWKS)
+}
+
+// signJWT signs a token for userID in namespace ns, carrying privileges, with key, valid for
+// ttl. privileges is what authorizeField checks a caller's subsequent requests against, so it
+// must reflect the same privilege set resolveLogin computed for userID at login time.
+func signJWT(key *jwtKey, audience, userID string, ns uint64, privileges []string, ttl time.Duration) (string, error) {
+	method, err := key.signingMethod()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"userid":    userID,
+		"namespace": ns,
+		"iat":       now.Unix(),
+		"exp":       now.Add(ttl).Unix(),
+	}
+	if audience != "" {
+		claims["aud"] = audience
+	}
+	if len(privileges) > 0 {
+		claims["privileges"] = privileges
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.signingKeyMaterial())
+}
+
+// verifyJWT checks tokenStr's signature against whichever currently-published key its kid
+// header names — any published key may verify a token, not just the newest — and returns its
+// claims.
+func verifyJWT(ks *jwtKeySet, tokenStr string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := ks.keyForKid(kid)
+		if err != nil {
+			return nil, err
+		}
+		return key.verifyKeyMaterial(), nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "while verifying JWT")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// resolveLogin implements the login mutation: a fresh userId/password/namespace login, or a
+// refresh using a previously issued refreshJWT. Either way it returns a new access token and —
+// since the refresh token is rotated on every use — a new refresh token too.
+func resolveLogin(ctx context.Context, field *ast.Field, vars map[string]interface{}) (interface{}, error) {
+	key, err := defaultJWTKeySet.signingKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var userID string
+	var ns uint64
+	var privileges []string
+
+	if refreshToken := asString(argValue(field, "refreshToken", vars)); refreshToken != "" {
+		claims, err := verifyJWT(defaultJWTKeySet, refreshToken)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while refreshing login")
+		}
+		userID, _ = claims["userid"].(string)
+		nsClaim, _ := claims["namespace"].(float64)
+		ns = uint64(nsClaim)
+		privileges = stringsFromClaim(claims["privileges"])
+	} else {
+		userID = asString(argValue(field, "userId", vars))
+
+		// Unlike a refresh, which carries its own namespace inside the token being
+		// refreshed, a fresh login has nothing trustworthy to fall back on: the caller
+		// isn't authenticated yet, so there's no JWT on ctx to read a namespace out of.
+		// It's therefore required explicitly, and must match the namespace the user
+		// belongs to.
+		nsArg := argValue(field, "namespace", vars)
+		if nsArg == nil {
+			return nil, errors.New("login requires namespace unless refreshToken is given")
+		}
+		ns = uint64(asInt(nsArg))
+
+		rec, ok := adminStore.user(userID)
+		if !ok || rec.namespace != ns {
+			return nil, errors.New("invalid username or password")
+		}
+		password := asString(argValue(field, "password", vars))
+		if err := bcrypt.CompareHashAndPassword(rec.passwordHash, []byte(password)); err != nil {
+			return nil, errors.New("invalid username or password")
+		}
+		privileges = privilegesForUser(rec)
+	}
+
+	audience := defaultJWTKeySet.getAudience()
+
+	access, err := signJWT(key, audience, userID, ns, privileges, accessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := signJWT(key, audience, userID, ns, privileges, refreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"response": map[string]interface{}{
+			"accessJWT":  access,
+			"refreshJWT": refresh,
+			"expiresIn":  int(accessTokenTTL.Seconds()),
+			"tokenType":  "Bearer",
+		},
+	}, nil
+}
+
+// resolveUpdateJWTKeys implements the updateJWTKeys mutation: it generates and publishes a
+// new signing key, making it the one used for tokens issued from now on, while every
+// previously published key stays valid for verification.
+func resolveUpdateJWTKeys(ctx context.Context, field *ast.Field, vars map[string]interface{}) (interface{}, error) {
+	if err := authorizeNamespace(ctx, galaxyNamespace); err != nil {
+		return nil, err
+	}
+
+	input := argValue(field, "input", vars)
+	alg := asString(objVal(input, "alg"))
+
+	var key *jwtKey
+	var err error
+	switch alg {
+	case "EdDSA":
+		key, err = newEd25519Key(nextKid())
+	case "HS256":
+		key, err = newHMACKey(nextKid())
+	default:
+		return nil, errors.Errorf("unsupported JWT algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if audience := asString(objVal(input, "audience")); audience != "" {
+		defaultJWTKeySet.setAudience(audience)
+	}
+	defaultJWTKeySet.publish(key)
+
+	return map[string]interface{}{"keys": defaultJWTKeySet.publicKeys()}, nil
+}
+
+// resolveJWKS implements the jwks query.
+func resolveJWKS(ctx context.Context, field *ast.Field, vars map[string]interface{}) (interface{}, error) {
+	return defaultJWTKeySet.publicKeys(), nil
+}
+
+func (k *jwtKey) signingMethod() (jwt.SigningMethod, error) {
+	switch k.alg {
+	case "EdDSA":
+		return jwt.SigningMethodEdDSA, nil
+	case "HS256":
+		return jwt.SigningMethodHS256, nil
+	default:
+		return nil, errors.Errorf("unsupported JWT algorithm %q", k.alg)
+	}
+}
+
+// signingKeyMaterial returns the value jwt-go needs to sign a token with k.
+func (k *jwtKey) signingKeyMaterial() interface{} {
+	if k.alg == "HS256" {
+		return k.hmacSecret
+	}
+	return k.private
+}
+
+// verifyKeyMaterial returns the value jwt-go needs to verify a token signed with k.
+func (k *jwtKey) verifyKeyMaterial() interface{} {
+	if k.alg == "HS256" {
+		return k.hmacSecret
+	}
+	return k.public
+}
+
+// publicPEM renders k's public key as PEM, or "" for an HMAC key whose secret must never be
+// exposed through the jwks query.
+func (k *jwtKey) publicPEM() string {
+	if k.alg == "HS256" {
+		return ""
+	}
+	pub, ok := k.public.(ed25519.PublicKey)
+	if !ok {
+		return ""
+	}
+	block := &pem.Block{Type: "PUBLIC KEY", Bytes: pub}
+	return string(pem.EncodeToMemory(block))
+}
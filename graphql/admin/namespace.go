@@ -0,0 +1,302 @@
+// +build !oss
+
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/v2/ast"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// galaxyNamespace is the id of the default namespace. Guardians of the galaxy are allowed
+// to see and mutate entities across every namespace; all other guardians are confined to
+// their own.
+const galaxyNamespace uint64 = 0
+
+// guardiansGroup is the name of the group whose members are administrators: full ACL and
+// backup/restore privileges within their own namespace, plus galaxy-guardian privileges (and
+// so access to every namespace) if that namespace is the galaxy.
+const guardiansGroup = "guardians"
+
+// namespaceContextKey is the context.Context key under which the caller's namespace,
+// extracted from its JWT claims, is stashed by authenticate ahead of admin operation
+// resolution — the same place that calls ContextWithPrivileges.
+type namespaceContextKey struct{}
+
+// ContextWithNamespace returns a context carrying ns as the caller's namespace.
+func ContextWithNamespace(ctx context.Context, ns uint64) context.Context {
+	return context.WithValue(ctx, namespaceContextKey{}, ns)
+}
+
+// namespaceFromContext returns the namespace the caller's JWT was issued for. It errors when
+// ctx carries no namespace, which is the unauthenticated case.
+func namespaceFromContext(ctx context.Context) (uint64, error) {
+	ns, ok := ctx.Value(namespaceContextKey{}).(uint64)
+	if !ok {
+		return 0, errors.New("no namespace present on context")
+	}
+	return ns, nil
+}
+
+// authorizeNamespace makes sure that the caller's namespace either matches ns, or the
+// caller is a guardian of the galaxy, which may operate on any namespace.
+func authorizeNamespace(ctx context.Context, ns uint64) error {
+	callerNs, err := namespaceFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if callerNs == galaxyNamespace || callerNs == ns {
+		return nil
+	}
+	return errors.Errorf("namespace %d is not visible to a guardian of namespace %d",
+		ns, callerNs)
+}
+
+// rejectCrossNamespaceRef checks that a GroupRef/RuleRef style input, resolved to the
+// namespace refNs, isn't being attached to an entity from a different namespace.
+func rejectCrossNamespaceRef(ctx context.Context, refNs uint64) error {
+	callerNs, err := namespaceFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if callerNs != galaxyNamespace && callerNs != refNs {
+		return errors.Errorf(
+			"cannot reference an entity from namespace %d while operating in namespace %d",
+			refNs, callerNs)
+	}
+	return nil
+}
+
+// privilegesForUser derives the privilege labels a successful login for rec should carry. Only
+// members of guardiansGroup are granted any: full ACL and backup/restore privileges within
+// their own namespace, plus galaxy-guardian if that namespace is the galaxy. Every other user
+// logs in with no admin privileges at all.
+func privilegesForUser(rec *userRecord) []string {
+	isGuardian := false
+	for _, g := range rec.groups {
+		if g == guardiansGroup {
+			isGuardian = true
+			break
+		}
+	}
+	if !isGuardian {
+		return nil
+	}
+
+	privileges := []string{"acl-read", "acl-write", "backup", "restore"}
+	if rec.namespace == galaxyNamespace {
+		privileges = append(privileges, "galaxy-guardian")
+	}
+	return privileges
+}
+
+func init() {
+	registerAdminResolver("addNamespace", resolveAddNamespace)
+	registerAdminResolver("deleteNamespace", resolveDeleteNamespace)
+	registerAdminResolver("resetPassword", resolveResetPassword)
+	registerAdminResolver("queryNamespace", resolveQueryNamespace)
+	registerAdminResolver("addUser", resolveAddUser)
+	registerAdminResolver("addGroup", resolveAddGroup)
+}
+
+// resolveAddNamespace implements the addNamespace mutation. Only a guardian of the galaxy may
+// create a namespace.
+func resolveAddNamespace(ctx context.Context, field *ast.Field, vars map[string]interface{}) (interface{}, error) {
+	if err := authorizeNamespace(ctx, galaxyNamespace); err != nil {
+		return nil, err
+	}
+
+	input := argValue(field, "input", vars)
+	rec := adminStore.addNamespace(
+		asString(objVal(input, "name")),
+		asInt(objVal(input, "maxUsers")),
+		asInt(objVal(input, "maxGroups")))
+
+	return map[string]interface{}{"namespace": namespacePayload(rec)}, nil
+}
+
+// resolveDeleteNamespace implements the deleteNamespace mutation. Only a guardian of the
+// galaxy may delete a namespace, and doing so removes every user and group scoped to it.
+func resolveDeleteNamespace(ctx context.Context, field *ast.Field, vars map[string]interface{}) (interface{}, error) {
+	if err := authorizeNamespace(ctx, galaxyNamespace); err != nil {
+		return nil, err
+	}
+
+	id := uint64(asInt(argValue(field, "id", vars)))
+	msg := adminStore.deleteNamespace(id)
+	return map[string]interface{}{"msg": msg}, nil
+}
+
+// resolveResetPassword implements the resetPassword mutation. A guardian may only reset
+// passwords for users in its own namespace; a guardian of the galaxy may reset a password in
+// any namespace.
+func resolveResetPassword(ctx context.Context, field *ast.Field, vars map[string]interface{}) (interface{}, error) {
+	input := argValue(field, "input", vars)
+	var ns uint64
+	if nsArg := objVal(input, "namespace"); nsArg != nil {
+		ns = uint64(asInt(nsArg))
+	} else {
+		callerNs, err := namespaceFromContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ns = callerNs
+	}
+	if err := authorizeNamespace(ctx, ns); err != nil {
+		return nil, err
+	}
+
+	userID := asString(objVal(input, "userId"))
+	hash, err := bcrypt.GenerateFromPassword([]byte(asString(objVal(input, "password"))), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while hashing new password for %s", userID)
+	}
+	if err := adminStore.setPasswordHash(userID, ns, hash); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"userId":    userID,
+		"namespace": ns,
+	}, nil
+}
+
+// resolveQueryNamespace implements the queryNamespace query, scoping the result to the
+// namespaces visible to the caller: every namespace for a guardian of the galaxy, only its
+// own for anyone else.
+func resolveQueryNamespace(ctx context.Context, field *ast.Field, vars map[string]interface{}) (interface{}, error) {
+	callerNs, err := namespaceFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recs := adminStore.listNamespaces(callerNs)
+	out := make([]interface{}, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, namespacePayload(rec))
+	}
+	return out, nil
+}
+
+// resolveAddUser implements the addUser mutation: each new user is recorded under the
+// caller's own namespace, with its password hashed before it's stored, and linked to the
+// GroupRefs given — creating any group that doesn't already exist, same as the schema
+// documents, as long as it isn't a cross-namespace reference. Every GroupRef must already
+// belong to the caller's own namespace (or the caller must be a guardian of the galaxy).
+func resolveAddUser(ctx context.Context, field *ast.Field, vars map[string]interface{}) (interface{}, error) {
+	callerNs, err := namespaceFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []interface{}
+	for _, item := range asList(argValue(field, "input", vars)) {
+		name := asString(objVal(item, "name"))
+		if name == "" {
+			continue
+		}
+
+		groupNames := make([]string, 0)
+		for _, groupRef := range asList(objVal(item, "groups")) {
+			groupName := asString(objVal(groupRef, "name"))
+			if groupName == "" {
+				continue
+			}
+			if rec, ok := adminStore.group(groupName); ok {
+				if err := rejectCrossNamespaceRef(ctx, rec.namespace); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := adminStore.getOrCreateGroup(groupName, callerNs); err != nil {
+				return nil, err
+			}
+			groupNames = append(groupNames, groupName)
+		}
+
+		password := asString(objVal(item, "password"))
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while hashing password for %s", name)
+		}
+
+		rec, err := adminStore.putUser(name, callerNs, hash, groupNames)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, userPayload(rec))
+	}
+	return map[string]interface{}{"user": users}, nil
+}
+
+// resolveAddGroup implements the addGroup mutation: the new group is recorded under the
+// caller's own namespace, subject to that namespace's maxGroups quota.
+func resolveAddGroup(ctx context.Context, field *ast.Field, vars map[string]interface{}) (interface{}, error) {
+	callerNs, err := namespaceFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []interface{}
+	for _, item := range asList(argValue(field, "input", vars)) {
+		name := asString(objVal(item, "name"))
+		if name == "" {
+			continue
+		}
+		rec, err := adminStore.putGroup(name, callerNs)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, groupPayload(rec))
+	}
+	return map[string]interface{}{"group": groups}, nil
+}
+
+// namespacePayload renders a namespaceRecord the way the Namespace GraphQL type expects it.
+func namespacePayload(rec *namespaceRecord) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        rec.id,
+		"name":      rec.name,
+		"maxUsers":  rec.maxUsers,
+		"maxGroups": rec.maxGroups,
+	}
+}
+
+// userPayload renders a userRecord the way the User GraphQL type expects it. password is never
+// included: dgraph.password is a @secret predicate, write-only from the GraphQL API.
+func userPayload(rec *userRecord) map[string]interface{} {
+	groups := make([]interface{}, len(rec.groups))
+	for i, name := range rec.groups {
+		groups[i] = map[string]interface{}{"name": name}
+	}
+	return map[string]interface{}{
+		"name":      rec.name,
+		"groups":    groups,
+		"namespace": map[string]interface{}{"id": rec.namespace},
+	}
+}
+
+// groupPayload renders a groupRecord the way the Group GraphQL type expects it.
+func groupPayload(rec *groupRecord) map[string]interface{} {
+	return map[string]interface{}{
+		"name":      rec.name,
+		"namespace": map[string]interface{}{"id": rec.namespace},
+	}
+}
@@ -0,0 +1,127 @@
+// +build !oss
+
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// privilegeContextKey is the context.Context key under which the caller's privilege labels,
+// extracted from its JWT claims, are stashed by the auth middleware that runs ahead of admin
+// operation resolution.
+type privilegeContextKey struct{}
+
+// ContextWithPrivileges returns a context carrying labels as the caller's privilege set. The
+// auth middleware calls this once it has verified a caller's JWT and extracted the
+// "privileges" claim; ResolveAdminOperation reads it back out via privilegeSetFromContext.
+func ContextWithPrivileges(ctx context.Context, labels []string) context.Context {
+	return context.WithValue(ctx, privilegeContextKey{}, labels)
+}
+
+// privilegeSetFromContext recovers the privilegeSet stashed by ContextWithPrivileges. A
+// context with no privileges attached — the unauthenticated case — yields an empty set
+// rather than an error, since label-free fields must stay reachable without a JWT.
+func privilegeSetFromContext(ctx context.Context) privilegeSet {
+	labels, _ := ctx.Value(privilegeContextKey{}).([]string)
+	return newPrivilegeSet(labels)
+}
+
+// requiresPrivilegeDirective is the name of the directive declared in adminDirectives that
+// gates access to a root field behind a set of privilege labels.
+const requiresPrivilegeDirective = "requiresPrivilege"
+
+// privilegeSet is the set of privilege labels carried by a caller's JWT claims. An empty
+// set is valid: it's what an unauthenticated caller has, and it's still enough to run any
+// root field that declares no @requiresPrivilege directive.
+type privilegeSet map[string]bool
+
+// newPrivilegeSet builds a privilegeSet out of the labels found in a caller's JWT claims.
+func newPrivilegeSet(labels []string) privilegeSet {
+	ps := make(privilegeSet, len(labels))
+	for _, l := range labels {
+		ps[l] = true
+	}
+	return ps
+}
+
+func (ps privilegeSet) hasAny(labels []string) bool {
+	if len(labels) == 0 {
+		return true
+	}
+	for _, l := range labels {
+		if ps[l] {
+			return true
+		}
+	}
+	return false
+}
+
+func (ps privilegeSet) hasAll(labels []string) bool {
+	for _, l := range labels {
+		if !ps[l] {
+			return false
+		}
+	}
+	return true
+}
+
+// authorizeField checks def's @requiresPrivilege directive (if any) against ps, returning a
+// structured error listing the missing privileges when the caller doesn't qualify.
+func (ps privilegeSet) authorizeField(def *ast.FieldDefinition) error {
+	directive := def.Directives.ForName(requiresPrivilegeDirective)
+	if directive == nil {
+		return nil
+	}
+
+	any := stringListArg(directive, "any")
+	all := stringListArg(directive, "all")
+
+	var missing []string
+	if !ps.hasAny(any) {
+		missing = append(missing, any...)
+	}
+	for _, l := range all {
+		if !ps[l] {
+			missing = append(missing, l)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return errors.Errorf(
+		"%s is missing privileges %v (needs one of %v and all of %v)", def.Name, missing, any, all)
+}
+
+func stringListArg(d *ast.Directive, name string) []string {
+	arg := d.Arguments.ForName(name)
+	if arg == nil || arg.Value == nil {
+		return nil
+	}
+
+	children := arg.Value.Children
+	labels := make([]string, 0, len(children))
+	for _, c := range children {
+		labels = append(labels, c.Value.Raw)
+	}
+	return labels
+}
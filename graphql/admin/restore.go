@@ -0,0 +1,300 @@
+// +build !oss
+
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// restoreStatusKind mirrors the RestoreStatusKind enum in adminTypes.
+type restoreStatusKind string
+
+const (
+	restoreNotFound restoreStatusKind = "NotFound"
+	restorePending  restoreStatusKind = "Pending"
+	restoreRunning  restoreStatusKind = "Running"
+	restoreFailed   restoreStatusKind = "Failed"
+	restoreDone     restoreStatusKind = "Done"
+)
+
+// restoreProgress is the in-memory record of an in-flight or completed restore, keyed by
+// restoreId and polled by the restoreStatus query.
+type restoreProgress struct {
+	status restoreStatusKind
+	errors []string
+}
+
+// restoreTracker hands out restoreIds and tracks the progress of every restore started since
+// this alpha came up, so that restoreStatus can be polled after the restore mutation returns.
+type restoreTracker struct {
+	mu       sync.RWMutex
+	inFlight map[string]*restoreProgress
+}
+
+func newRestoreTracker() *restoreTracker {
+	return &restoreTracker{inFlight: make(map[string]*restoreProgress)}
+}
+
+func (rt *restoreTracker) start(restoreID string) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.inFlight[restoreID] = &restoreProgress{status: restorePending}
+}
+
+func (rt *restoreTracker) setStatus(restoreID string, status restoreStatusKind, errs ...string) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	p, ok := rt.inFlight[restoreID]
+	if !ok {
+		p = &restoreProgress{}
+		rt.inFlight[restoreID] = p
+	}
+	p.status = status
+	p.errors = errs
+}
+
+func (rt *restoreTracker) get(restoreID string) *restoreProgress {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	if p, ok := rt.inFlight[restoreID]; ok {
+		return p
+	}
+	return &restoreProgress{status: restoreNotFound}
+}
+
+// restoreManifest is the subset of a backup manifest.json needed to plan a restore. Types
+// resolves each type name to the predicates it references, which is how buildRestorePlan turns
+// a `types` filter into the set of predicates it needs to replay. manifestsForBackup leaves
+// Types empty, since manifest.json doesn't record that mapping.
+type restoreManifest struct {
+	Timestamp uint64
+	Groups    map[uint32][]string // group id -> predicates backed up by that group
+	Types     map[string][]string // type name -> predicates it references
+}
+
+// buildRestorePlan selects the manifests inside [sinceTs, untilTs] and, within those, the
+// groups and predicates matching the predicates/types filters. An empty predicates/types
+// filter matches everything, consistent with the other admin list filters in this package.
+//
+// predicates and types are independent allow-lists that are unioned together: a predicate
+// backed up by a group is kept if it's named directly in predicates, or if it's referenced by
+// a type named in types. types is resolved per-manifest via that manifest's own Types lookup,
+// since which predicates a type references can change between backups.
+func buildRestorePlan(manifests []*restoreManifest, sinceTs, untilTs uint64,
+	predicates, types []string) (*restorePlan, error) {
+
+	if untilTs != 0 && sinceTs > untilTs {
+		return nil, errors.Errorf("sinceTs (%d) must not be greater than untilTs (%d)",
+			sinceTs, untilTs)
+	}
+
+	wantedPredicates := make(map[string]bool, len(predicates))
+	for _, p := range predicates {
+		wantedPredicates[p] = true
+	}
+	restoreEverything := len(predicates) == 0 && len(types) == 0
+
+	plan := &restorePlan{}
+	groupSet := make(map[uint32]bool)
+	predSet := make(map[string]bool)
+
+	for _, m := range manifests {
+		if m.Timestamp < sinceTs {
+			continue
+		}
+		if untilTs != 0 && m.Timestamp > untilTs {
+			continue
+		}
+		plan.manifests = append(plan.manifests, m.Timestamp)
+
+		manifestWanted := wantedPredicates
+		if len(types) > 0 {
+			manifestWanted = cloneStringSet(wantedPredicates)
+			for _, t := range types {
+				for _, p := range m.Types[t] {
+					manifestWanted[p] = true
+				}
+			}
+		}
+
+		for gid, preds := range m.Groups {
+			for _, p := range preds {
+				if !restoreEverything && !manifestWanted[p] {
+					continue
+				}
+				groupSet[gid] = true
+				predSet[p] = true
+			}
+		}
+	}
+
+	for gid := range groupSet {
+		plan.groups = append(plan.groups, gid)
+	}
+	for p := range predSet {
+		plan.predicates = append(plan.predicates, p)
+	}
+	return plan, nil
+}
+
+func cloneStringSet(s map[string]bool) map[string]bool {
+	out := make(map[string]bool, len(s))
+	for k, v := range s {
+		out[k] = v
+	}
+	return out
+}
+
+// restorePlan is the Go-side mirror of the RestorePlan GraphQL type.
+type restorePlan struct {
+	manifests  []uint64
+	groups     []uint32
+	predicates []string
+}
+
+func restorePlanPayload(p *restorePlan) map[string]interface{} {
+	manifests := make([]interface{}, len(p.manifests))
+	for i, ts := range p.manifests {
+		manifests[i] = ts
+	}
+	groups := make([]interface{}, len(p.groups))
+	for i, gid := range p.groups {
+		groups[i] = gid
+	}
+	return map[string]interface{}{
+		"manifests":  manifests,
+		"groups":     groups,
+		"predicates": p.predicates,
+	}
+}
+
+// manifestsForBackup lists the manifests making up the backup series identified by location
+// and backupID, by reading manifest.json out of location on the local filesystem (a "file://"
+// URI, or a plain path). Object-store destinations (Minio, S3) aren't implemented by this
+// build; var rather than func so a test can swap it for a fake without touching disk.
+var manifestsForBackup = func(ctx context.Context, location, backupID string) ([]*restoreManifest, error) {
+	path := strings.TrimPrefix(location, "file://")
+	manifestPath := filepath.Join(path, "manifest.json")
+
+	data, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"while reading manifest.json for backup %q; only a local-filesystem location "+
+				"(a plain path, or a file:// URI) is supported by this build", backupID)
+	}
+
+	var onDisk struct {
+		Manifests []struct {
+			Since  uint64              `json:"since"`
+			Groups map[uint32][]string `json:"groups"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(data, &onDisk); err != nil {
+		return nil, errors.Wrapf(err, "while parsing manifest.json at %q", manifestPath)
+	}
+
+	manifests := make([]*restoreManifest, 0, len(onDisk.Manifests))
+	for _, m := range onDisk.Manifests {
+		manifests = append(manifests, &restoreManifest{
+			Timestamp: m.Since,
+			Groups:    m.Groups,
+			// manifest.json records the predicates each group backed up, but not which
+			// dgraph.type each predicate belongs to, so a types filter can't be resolved
+			// to predicates from this file alone; Types is left empty until that mapping
+			// is sourced from the schema snapshot taken at backup time.
+			Types: map[string][]string{},
+		})
+	}
+	return manifests, nil
+}
+
+var restores = newRestoreTracker()
+
+func init() {
+	registerAdminResolver("restore", resolveRestore)
+	registerAdminResolver("restoreStatus", resolveRestoreStatus)
+}
+
+// resolveRestore implements the restore mutation: it always computes a RestorePlan from the
+// sinceTs/untilTs/predicates/types filters against the real manifest.json read by
+// manifestsForBackup, returns it as-is for a dryRun, and otherwise registers the restore as
+// Pending and returns a restoreId the caller can poll via restoreStatus.
+//
+// Replaying a backup's data into this cluster's storage engine is the job of the worker
+// package, which drives a Raft proposal across every alpha in each affected group; no such
+// handoff exists in this package yet, so a non-dryRun restore is only ever reported as Pending
+// — never as Running or Done — rather than claim a replay that hasn't happened.
+func resolveRestore(ctx context.Context, field *ast.Field, vars map[string]interface{}) (interface{}, error) {
+	input := argValue(field, "input", vars)
+	location := asString(objVal(input, "location"))
+	backupID := asString(objVal(input, "backupId"))
+	sinceTs := uint64(asInt(objVal(input, "sinceTs")))
+	untilTs := uint64(asInt(objVal(input, "untilTs")))
+	predicates := asStringList(objVal(input, "predicates"))
+	types := asStringList(objVal(input, "types"))
+	dryRun := asBool(objVal(input, "dryRun"))
+
+	manifests, err := manifestsForBackup(ctx, location, backupID)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := buildRestorePlan(manifests, sinceTs, untilTs, predicates, types)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]interface{}{"plan": restorePlanPayload(plan)}
+	if dryRun {
+		return payload, nil
+	}
+	if len(plan.manifests) == 0 {
+		return nil, errors.Errorf(
+			"no backup manifests for %q fall inside the requested window; nothing to restore",
+			backupID)
+	}
+
+	restoreID := newRestoreID()
+	restores.start(restoreID)
+
+	payload["restoreId"] = restoreID
+	payload["response"] = map[string]interface{}{
+		"code":    "Success",
+		"message": "Restore plan validated and recorded as Pending; no replay has been started.",
+	}
+	return payload, nil
+}
+
+// resolveRestoreStatus implements the restoreStatus query.
+func resolveRestoreStatus(ctx context.Context, field *ast.Field, vars map[string]interface{}) (interface{}, error) {
+	restoreID := asString(argValue(field, "restoreId", vars))
+	p := restores.get(restoreID)
+	return map[string]interface{}{
+		"restoreId": restoreID,
+		"status":    string(p.status),
+		"errors":    p.errors,
+	}, nil
+}
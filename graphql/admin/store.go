@@ -0,0 +1,254 @@
+// +build !oss
+
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// namespaceRecord is the Go-side mirror of a Namespace node.
+type namespaceRecord struct {
+	id        uint64
+	name      string
+	maxUsers  int
+	maxGroups int
+}
+
+// groupRecord is the subset of a Group node the admin package itself needs to track in order
+// to enforce namespace scoping; the group's rules live entirely in the backing Dgraph
+// cluster and aren't duplicated here.
+type groupRecord struct {
+	name      string
+	namespace uint64
+}
+
+// userRecord is the subset of a User node the admin package tracks: enough to authenticate a
+// login and to enforce namespace scoping and guardiansGroup membership on it.
+type userRecord struct {
+	name         string
+	namespace    uint64
+	passwordHash []byte
+	groups       []string
+}
+
+// adminStoreT holds every namespace, group and user the admin resolvers in this package know
+// about. It is this alpha's only copy of that data: nothing in this package yet persists it to
+// or loads it from the Dgraph cluster's own dgraph.type User/Group/Namespace predicates, so a
+// restart or a second alpha in the same cluster won't see what it records. Until this package
+// is wired into the generic add/update mutation path that owns those predicates, every admin
+// query or mutation in a request must land on this same process to see consistent results.
+type adminStoreT struct {
+	mu         sync.RWMutex
+	nextNsID   uint64
+	namespaces map[uint64]*namespaceRecord
+	groups     map[string]*groupRecord
+	users      map[string]*userRecord
+}
+
+var adminStore = newAdminStore()
+
+// newAdminStore seeds the galaxy namespace with the same default groot/guardians bootstrap
+// every Dgraph cluster starts with: a guardiansGroup group, and a groot user in it whose
+// password is "password" until an operator changes it via resetPassword.
+func newAdminStore() *adminStoreT {
+	s := &adminStoreT{
+		namespaces: map[uint64]*namespaceRecord{galaxyNamespace: {id: galaxyNamespace, name: "galaxy"}},
+		groups:     map[string]*groupRecord{guardiansGroup: {name: guardiansGroup, namespace: galaxyNamespace}},
+		users:      map[string]*userRecord{},
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	s.users["groot"] = &userRecord{
+		name: "groot", namespace: galaxyNamespace, passwordHash: hash, groups: []string{guardiansGroup},
+	}
+	return s
+}
+
+func (s *adminStoreT) addNamespace(name string, maxUsers, maxGroups int) *namespaceRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextNsID++
+	rec := &namespaceRecord{id: s.nextNsID, name: name, maxUsers: maxUsers, maxGroups: maxGroups}
+	s.namespaces[rec.id] = rec
+	return rec
+}
+
+func (s *adminStoreT) deleteNamespace(id uint64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.namespaces[id]; !ok {
+		return "no namespace found with the given id"
+	}
+	delete(s.namespaces, id)
+	for name, g := range s.groups {
+		if g.namespace == id {
+			delete(s.groups, name)
+		}
+	}
+	for name, u := range s.users {
+		if u.namespace == id {
+			delete(s.users, name)
+		}
+	}
+	return "Deleted namespace successfully"
+}
+
+// listNamespaces returns every namespace visible to callerNs: all of them for the galaxy,
+// just its own otherwise.
+func (s *adminStoreT) listNamespaces(callerNs uint64) []*namespaceRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*namespaceRecord, 0, len(s.namespaces))
+	for _, ns := range s.namespaces {
+		if callerNs == galaxyNamespace || ns.id == callerNs {
+			out = append(out, ns)
+		}
+	}
+	return out
+}
+
+func (s *adminStoreT) group(name string) (*groupRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.groups[name]
+	return g, ok
+}
+
+// putGroup creates a new group under namespace, rejecting the write once the namespace's
+// maxGroups quota (if any) is reached. Dgraph ensures group names are unique, so re-adding an
+// existing name is rejected rather than overwriting it.
+func (s *adminStoreT) putGroup(name string, namespace uint64) (*groupRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.groups[name]; exists {
+		return nil, errors.Errorf("group %q already exists", name)
+	}
+	rec, err := s.createGroupLocked(name, namespace)
+	return rec, err
+}
+
+// getOrCreateGroup implements addUser's documented GroupRef behaviour: link to the group if it
+// already exists, or create it under namespace otherwise.
+func (s *adminStoreT) getOrCreateGroup(name string, namespace uint64) (*groupRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, exists := s.groups[name]; exists {
+		return rec, nil
+	}
+	return s.createGroupLocked(name, namespace)
+}
+
+// createGroupLocked creates a new group under namespace. s.mu must be held by the caller.
+func (s *adminStoreT) createGroupLocked(name string, namespace uint64) (*groupRecord, error) {
+	if ns, ok := s.namespaces[namespace]; ok && ns.maxGroups > 0 {
+		count := 0
+		for _, g := range s.groups {
+			if g.namespace == namespace {
+				count++
+			}
+		}
+		if count >= ns.maxGroups {
+			return nil, errNamespaceQuotaExceeded
+		}
+	}
+	rec := &groupRecord{name: name, namespace: namespace}
+	s.groups[name] = rec
+	return rec, nil
+}
+
+func (s *adminStoreT) user(name string) (*userRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[name]
+	return u, ok
+}
+
+// putUser records a user under namespace with the given password hash and group membership,
+// rejecting the write once the namespace's maxUsers quota (if any) is reached. Dgraph ensures
+// usernames are unique, so re-adding an existing name is rejected rather than overwriting it.
+func (s *adminStoreT) putUser(name string, namespace uint64, passwordHash []byte, groups []string) (*userRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[name]; exists {
+		return nil, errors.Errorf("user %q already exists", name)
+	}
+	if ns, ok := s.namespaces[namespace]; ok && ns.maxUsers > 0 {
+		count := 0
+		for _, u := range s.users {
+			if u.namespace == namespace {
+				count++
+			}
+		}
+		if count >= ns.maxUsers {
+			return nil, errNamespaceQuotaExceeded
+		}
+	}
+	rec := &userRecord{name: name, namespace: namespace, passwordHash: passwordHash, groups: groups}
+	s.users[name] = rec
+	return rec, nil
+}
+
+// setPasswordHash overwrites the password hash of the user name in namespace, used by
+// resetPassword.
+func (s *adminStoreT) setPasswordHash(name string, namespace uint64, hash []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[name]
+	if !ok || u.namespace != namespace {
+		return errors.Errorf("no user %q found in namespace %d", name, namespace)
+	}
+	u.passwordHash = hash
+	return nil
+}
+
+var restoreIDCounter uint64
+
+// newRestoreID hands out a unique id for each restore mutation invocation, used as the handle
+// clients poll via restoreStatus.
+func newRestoreID() string {
+	n := atomic.AddUint64(&restoreIDCounter, 1)
+	return "restore-" + itoa(n)
+}
+
+func itoa(n uint64) string {
+	if n == 0 {
+		return "0"
+	}
+	var buf [20]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = byte('0' + n%10)
+		n /= 10
+	}
+	return string(buf[i:])
+}
+
+var errNamespaceQuotaExceeded = errors.New("namespace quota exceeded")
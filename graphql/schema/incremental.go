@@ -0,0 +1,397 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// incrementalDeliveryDirectives declares @defer and @stream as specified by the GraphQL
+// incremental delivery RFC.
+const incrementalDeliveryDirectives = `
+	directive @defer(if: Boolean, label: String) on FRAGMENT_SPREAD | INLINE_FRAGMENT
+	directive @stream(initialCount: Int, label: String) on FIELD
+`
+
+// WithIncrementalDeliveryDirectives appends the @defer/@stream directive declarations to sdl,
+// so that @defer/@stream validate against a schema source passed through it before it's
+// handed to gqlparser. Schema sources not passed through it don't declare them.
+func WithIncrementalDeliveryDirectives(sdl string) string {
+	return sdl + incrementalDeliveryDirectives
+}
+
+const (
+	deferDirective  = "defer"
+	streamDirective = "stream"
+)
+
+// IncrementalPayload is one chunk of a streamed response: either the initial payload, or a
+// later patch produced by a deferred fragment or a streamed list field. It's serialized as
+// one part of a multipart/mixed response by IntrospectStream.
+type IncrementalPayload struct {
+	// Path locates the deferred data within the overall response shape, e.g. ["__schema"].
+	Path []interface{}   `json:"path,omitempty"`
+	Data json.RawMessage `json:"data"`
+	// Label is the optional label given to the @defer/@stream directive that produced this
+	// payload, letting a client tell multiple deferred fragments apart.
+	Label string `json:"label,omitempty"`
+	// HasNext is true on every payload except the last one sent down the channel.
+	HasNext bool `json:"hasNext"`
+}
+
+// selectionIsDeferred reports whether sel carries an @defer directive whose if argument (when
+// present) isn't the literal false. An if given as a $variable isn't looked up, so it counts
+// as true whatever the variable's value.
+func selectionIsDeferred(sel ast.Selection) bool {
+	d := deferDirectiveOf(sel)
+	if d == nil {
+		return false
+	}
+	if arg := d.Arguments.ForName("if"); arg != nil && arg.Value != nil {
+		return arg.Value.Raw != "false"
+	}
+	return true
+}
+
+func deferDirectiveOf(sel ast.Selection) *ast.Directive {
+	switch s := sel.(type) {
+	case *ast.FragmentSpread:
+		return s.Directives.ForName(deferDirective)
+	case *ast.InlineFragment:
+		return s.Directives.ForName(deferDirective)
+	default:
+		return nil
+	}
+}
+
+func deferLabelOf(sel ast.Selection) string {
+	d := deferDirectiveOf(sel)
+	if d == nil {
+		return ""
+	}
+	if arg := d.Arguments.ForName("label"); arg != nil && arg.Value != nil {
+		return arg.Value.Raw
+	}
+	return ""
+}
+
+// fragmentFieldNames returns the names of the fields directly selected inside sel's selection
+// set — the fields that belong to this deferred fragment.
+func fragmentFieldNames(sel ast.Selection) []string {
+	var inner ast.SelectionSet
+	switch s := sel.(type) {
+	case *ast.InlineFragment:
+		inner = s.SelectionSet
+	case *ast.FragmentSpread:
+		if s.Definition != nil {
+			inner = s.Definition.SelectionSet
+		}
+	}
+
+	names := make([]string, 0, len(inner))
+	for _, s := range inner {
+		if f, ok := s.(*ast.Field); ok {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+// deferredFragment is a single @defer-annotated selection pulled out of a query.
+type deferredFragment struct {
+	sel   ast.Selection
+	label string
+}
+
+// streamedField is a single @stream-annotated list field pulled out of a query: its first
+// initialCount elements go in the initial payload, the rest follow one per chunk.
+type streamedField struct {
+	name         string
+	label        string
+	initialCount int
+}
+
+func streamDirectiveOf(sel ast.Selection) *ast.Directive {
+	f, ok := sel.(*ast.Field)
+	if !ok {
+		return nil
+	}
+	return f.Directives.ForName(streamDirective)
+}
+
+func streamInitialCount(d *ast.Directive) int {
+	if arg := d.Arguments.ForName("initialCount"); arg != nil && arg.Value != nil {
+		n, _ := strconv.Atoi(arg.Value.Raw)
+		return n
+	}
+	return 0
+}
+
+func streamLabelOf(d *ast.Directive) string {
+	if arg := d.Arguments.ForName("label"); arg != nil && arg.Value != nil {
+		return arg.Value.Raw
+	}
+	return ""
+}
+
+// splitStreamedFields walks query's top-level selection set, picking out the @stream fields
+// directly nested under it — the same scope splitDeferredSelections applies to @defer, for the
+// same reason: streaming a field nested deeper would require resolving fields lazily rather
+// than slicing up one eagerly-computed response.
+func splitStreamedFields(query Query) ([]streamedField, error) {
+	field, ok := query.(interface{ SelectionSet() []ast.Selection })
+	if !ok {
+		return nil, nil
+	}
+
+	var streamed []streamedField
+	for _, sel := range field.SelectionSet() {
+		d := streamDirectiveOf(sel)
+		if d == nil {
+			continue
+		}
+		f := sel.(*ast.Field)
+		name := f.Name
+		if f.Alias != "" {
+			name = f.Alias
+		}
+		streamed = append(streamed, streamedField{
+			name:         name,
+			label:        streamLabelOf(d),
+			initialCount: streamInitialCount(d),
+		})
+	}
+	return streamed, nil
+}
+
+// splitDeferredSelections walks query's top-level selection set, picking out the @defer
+// fragments directly nested under it. The split happens once, up front, so that chunk
+// boundaries are deterministic for a given query rather than depending on resolution timing.
+//
+// Only fragments deferred directly under the query's single root field are split out; @defer
+// nested more than one level deep is left for a follow-up, since scoping it would require
+// resolving fields lazily rather than slicing up one eagerly-computed response.
+func splitDeferredSelections(query Query) ([]deferredFragment, error) {
+	field, ok := query.(interface{ SelectionSet() []ast.Selection })
+	if !ok {
+		return nil, nil
+	}
+
+	var deferred []deferredFragment
+	for _, sel := range field.SelectionSet() {
+		if !selectionIsDeferred(sel) {
+			continue
+		}
+		deferred = append(deferred, deferredFragment{sel: sel, label: deferLabelOf(sel)})
+	}
+	return deferred, nil
+}
+
+// IntrospectStream is the incremental-delivery sibling of Introspect. It resolves query once,
+// then splits the result into an initial payload plus one chunk per @defer fragment and one
+// chunk per element past initialCount of each @stream list field found directly under query's
+// root field — each chunk carrying only its own data, removed from the initial payload so
+// nothing is sent twice.
+//
+// The returned channel is closed after the final payload (HasNext: false) is sent.
+func IntrospectStream(query Query) (<-chan []byte, error) {
+	deferred, err := splitDeferredSelections(query)
+	if err != nil {
+		return nil, err
+	}
+	streamed, err := splitStreamedFields(query)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := Introspect(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deferred) == 0 && len(streamed) == 0 {
+		ch := make(chan []byte, 1)
+		ch <- mustMarshalIncremental(IncrementalPayload{Data: resp, HasNext: false})
+		close(ch)
+		return ch, nil
+	}
+
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(resp, &root); err != nil {
+		return nil, errors.Wrapf(err, "while splitting introspection response for streaming")
+	}
+
+	chunks := make([]IncrementalPayload, 0, len(deferred)+len(streamed))
+	for _, frag := range deferred {
+		data, path, ok := extractFragmentData(root, frag.sel)
+		if !ok {
+			continue
+		}
+		removeFragmentData(root, frag.sel)
+		chunks = append(chunks, IncrementalPayload{Path: path, Data: data, Label: frag.label})
+	}
+	for _, sf := range streamed {
+		streamChunks, err := extractStreamedChunks(root, sf)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, streamChunks...)
+	}
+
+	initialData, err := json.Marshal(root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while marshaling initial streamed payload")
+	}
+
+	ch := make(chan []byte, len(chunks)+1)
+	ch <- mustMarshalIncremental(IncrementalPayload{Data: initialData, HasNext: len(chunks) > 0})
+	for i, c := range chunks {
+		c.HasNext = i != len(chunks)-1
+		ch <- mustMarshalIncremental(c)
+	}
+	close(ch)
+
+	return ch, nil
+}
+
+// extractStreamedChunks replaces the field sf.name under root's root field with just its first
+// sf.initialCount elements, returning one IncrementalPayload per remaining element, each
+// addressed by its path in the full response: the root field name, sf.name and the element's
+// index. A field that isn't a JSON array (i.e. @stream applied to a non-list field) is left
+// untouched and yields no chunks, since streaming only makes sense for lists.
+func extractStreamedChunks(root map[string]json.RawMessage, sf streamedField) ([]IncrementalPayload, error) {
+	for rootName, rootRaw := range root {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(rootRaw, &obj); err != nil {
+			continue
+		}
+		raw, ok := obj[sf.name]
+		if !ok {
+			continue
+		}
+
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, nil
+		}
+
+		initialCount := sf.initialCount
+		if initialCount < 0 {
+			initialCount = 0
+		}
+		if initialCount > len(items) {
+			initialCount = len(items)
+		}
+
+		initialData, err := json.Marshal(items[:initialCount])
+		if err != nil {
+			return nil, errors.Wrapf(err, "while marshaling initial chunk of streamed field %q", sf.name)
+		}
+		obj[sf.name] = initialData
+		rootData, err := json.Marshal(obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while marshaling %q without streamed field %q", rootName, sf.name)
+		}
+		root[rootName] = rootData
+
+		chunks := make([]IncrementalPayload, 0, len(items)-initialCount)
+		for i := initialCount; i < len(items); i++ {
+			chunks = append(chunks, IncrementalPayload{
+				Path:  []interface{}{rootName, sf.name, i},
+				Data:  items[i],
+				Label: sf.label,
+			})
+		}
+		return chunks, nil
+	}
+	return nil, nil
+}
+
+// extractFragmentData pulls the fields named in sel's selection set out of root — the decoded
+// top-level introspection response — into their own object, returning it alongside the path
+// (the query's root field name) it belongs under.
+func extractFragmentData(root map[string]json.RawMessage, sel ast.Selection) (json.RawMessage, []interface{}, bool) {
+	names := fragmentFieldNames(sel)
+	if len(names) == 0 {
+		return nil, nil, false
+	}
+
+	for rootName, raw := range root {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			continue
+		}
+
+		out := make(map[string]json.RawMessage, len(names))
+		found := false
+		for _, n := range names {
+			if v, ok := obj[n]; ok {
+				out[n] = v
+				found = true
+			}
+		}
+		if !found {
+			continue
+		}
+		data, err := json.Marshal(out)
+		if err != nil {
+			return nil, nil, false
+		}
+		return data, []interface{}{rootName}, true
+	}
+	return nil, nil, false
+}
+
+// removeFragmentData deletes the fields named in sel's selection set from root's single root
+// field, so the initial payload doesn't duplicate data sent as a separate deferred chunk.
+func removeFragmentData(root map[string]json.RawMessage, sel ast.Selection) {
+	names := fragmentFieldNames(sel)
+	for rootName, raw := range root {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			continue
+		}
+
+		changed := false
+		for _, n := range names {
+			if _, ok := obj[n]; ok {
+				delete(obj, n)
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		if data, err := json.Marshal(obj); err == nil {
+			root[rootName] = data
+		}
+	}
+}
+
+func mustMarshalIncremental(p IncrementalPayload) []byte {
+	b, err := json.Marshal(p)
+	if err != nil {
+		// IncrementalPayload only ever holds JSON-safe data produced by Introspect, so
+		// marshaling can't fail in practice.
+		panic(err)
+	}
+	return b
+}
@@ -1,6 +1,7 @@
 package schema
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -257,6 +258,171 @@ func TestFullIntrospectionQuery(t *testing.T) {
 	testutil.CompareJSON(t, string(expectedBuf), string(resp))
 }
 
+func TestIntrospectStreamDeferredFragment(t *testing.T) {
+	sch := gqlparser.MustLoadSchema(
+		&ast.Source{Name: "schema.graphql", Input: WithIncrementalDeliveryDirectives(`
+	schema {
+		query: TestType
+	}
+
+	type TestType {
+		testField: String
+	}
+`)})
+
+	q := `{
+		__schema {
+			queryType { name }
+			... @defer(label: "types") {
+				types { name }
+			}
+		}
+	}`
+
+	doc, gqlErr := parser.ParseQuery(&ast.Source{Input: q})
+	require.Nil(t, gqlErr)
+	listErr := validator.Validate(sch, doc)
+	require.Equal(t, 0, len(listErr))
+
+	op := doc.Operations.ForName("")
+	require.NotNil(t, op)
+	oper := &operation{op: op,
+		vars:     map[string]interface{}{},
+		query:    q,
+		doc:      doc,
+		inSchema: &schema{schema: sch},
+	}
+
+	queries := oper.Queries()
+	ch, err := IntrospectStream(queries[0])
+	require.NoError(t, err)
+
+	var payloads []IncrementalPayload
+	for raw := range ch {
+		var p IncrementalPayload
+		require.NoError(t, json.Unmarshal(raw, &p))
+		payloads = append(payloads, p)
+	}
+
+	require.Equal(t, 2, len(payloads))
+	require.True(t, payloads[0].HasNext)
+	require.False(t, payloads[1].HasNext)
+
+	var initial map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(payloads[0].Data, &initial))
+	var initialSchema map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(initial["__schema"], &initialSchema))
+	_, hasTypes := initialSchema["types"]
+	require.False(t, hasTypes, "deferred field must not also appear in the initial payload")
+	_, hasQueryType := initialSchema["queryType"]
+	require.True(t, hasQueryType)
+
+	require.Equal(t, "types", payloads[1].Label)
+	require.Equal(t, []interface{}{"__schema"}, payloads[1].Path)
+	var deferredSchema map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(payloads[1].Data, &deferredSchema))
+	_, hasTypesInChunk := deferredSchema["types"]
+	require.True(t, hasTypesInChunk, "deferred chunk must carry the deferred field")
+}
+
+func TestIntrospectionQueryWithCustomDirective(t *testing.T) {
+	sch := gqlparser.MustLoadSchema(
+		&ast.Source{Name: "schema.graphql", Input: `
+	directive @requiresPrivilege(any: [String!], all: [String!]) on FIELD_DEFINITION
+
+	schema {
+		query: Query
+	}
+
+	type Query {
+		backup: String @requiresPrivilege(any: ["backup"])
+	}
+`})
+
+	doc, gqlErr := parser.ParseQuery(&ast.Source{Input: introspectionQuery})
+	require.Nil(t, gqlErr)
+	listErr := validator.Validate(sch, doc)
+	require.Equal(t, 0, len(listErr))
+
+	op := doc.Operations.ForName("")
+	require.NotNil(t, op)
+	oper := &operation{op: op,
+		vars:     map[string]interface{}{},
+		query:    string(introspectionQuery),
+		doc:      doc,
+		inSchema: &schema{schema: sch},
+	}
+
+	queries := oper.Queries()
+	resp, err := Introspect(queries[0])
+	require.NoError(t, err)
+
+	// A client needs to see a custom directive's definition in __Schema.directives in order
+	// to know which fields it can't call without the right privileges — the same way it
+	// already sees @deprecated, @include and @skip.
+	require.Contains(t, string(resp), "requiresPrivilege")
+}
+
+func TestIntrospectStreamedField(t *testing.T) {
+	sch := gqlparser.MustLoadSchema(
+		&ast.Source{Name: "schema.graphql", Input: WithIncrementalDeliveryDirectives(`
+	schema {
+		query: TestType
+	}
+
+	type TestType {
+		testField: String
+	}
+`)})
+
+	q := `{
+		__schema {
+			queryType { name }
+			types @stream(initialCount: 1, label: "types") { name }
+		}
+	}`
+
+	doc, gqlErr := parser.ParseQuery(&ast.Source{Input: q})
+	require.Nil(t, gqlErr)
+	listErr := validator.Validate(sch, doc)
+	require.Equal(t, 0, len(listErr))
+
+	op := doc.Operations.ForName("")
+	require.NotNil(t, op)
+	oper := &operation{op: op,
+		vars:     map[string]interface{}{},
+		query:    q,
+		doc:      doc,
+		inSchema: &schema{schema: sch},
+	}
+
+	queries := oper.Queries()
+	ch, err := IntrospectStream(queries[0])
+	require.NoError(t, err)
+
+	var payloads []IncrementalPayload
+	for raw := range ch {
+		var p IncrementalPayload
+		require.NoError(t, json.Unmarshal(raw, &p))
+		payloads = append(payloads, p)
+	}
+	require.True(t, len(payloads) > 1, "a streamed list with more than initialCount elements must produce follow-up chunks")
+
+	var initial map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(payloads[0].Data, &initial))
+	var initialSchema map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(initial["__schema"], &initialSchema))
+
+	var initialTypes []json.RawMessage
+	require.NoError(t, json.Unmarshal(initialSchema["types"], &initialTypes))
+	require.Equal(t, 1, len(initialTypes), "the initial payload must carry exactly initialCount elements")
+
+	last := payloads[len(payloads)-1]
+	require.False(t, last.HasNext)
+	require.Equal(t, "types", last.Label)
+	require.Equal(t, []interface{}{"__schema", "types"}, last.Path[:2])
+}
+
 func Test(t *testing.T) {
 	queryDoc, err := parser.ParseQuery(&ast.Source{Input: `mutation team(
 $postID: Int){relatedUsers(id:$postID,id:$postID)@test}query{test()}`})